@@ -0,0 +1,196 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ContentMode describes how a CloudEvents envelope is represented on the wire, per the
+// CloudEvents 1.0 spec (https://github.com/cloudevents/spec/blob/v1.0/spec.md#message).
+type ContentMode string
+
+const (
+	// ContentModeStructured carries the entire envelope as a single JSON document in the
+	// message body. This is the content mode Dapr has always used.
+	ContentModeStructured ContentMode = "structured"
+	// ContentModeBinary carries CloudEvents attributes as message metadata/headers and the
+	// event data, unmodified, as the message body.
+	ContentModeBinary ContentMode = "binary"
+
+	// ContentModeMetadataKey is the component metadata key used to declare which content
+	// mode(s) a pub/sub component wants to speak CloudEvents in.
+	ContentModeMetadataKey = "cloudEventContentMode"
+
+	ceHeaderID          = "ce-id"
+	ceHeaderSource      = "ce-source"
+	ceHeaderType        = "ce-type"
+	ceHeaderSpecVersion = "ce-specversion"
+	ceHeaderTime        = "ce-time"
+	ceHeaderSubject     = "ce-subject"
+	ceHeaderDataSchema  = "ce-dataschema"
+	ceHeaderTraceID     = "ce-traceid"
+	ceHeaderExpiration  = "ce-expiration"
+	ceHeaderContentType = "content-type"
+	ceHeaderExtPrefix   = "ce-"
+)
+
+// ceKnownHeaders are the ce-* headers BinaryV1 maps to a dedicated envelope field, rather
+// than an Extensions entry.
+var ceKnownHeaders = map[string]bool{
+	"id": true, "source": true, "type": true, "specversion": true,
+	"time": true, "subject": true, "dataschema": true,
+	"traceid": true, "expiration": true,
+}
+
+// Codec encodes a CloudEventsEnvelope into its wire representation and decodes it back,
+// per a specific CloudEvents content mode.
+type Codec interface {
+	Encode(env *CloudEventsEnvelope) (body []byte, headers map[string]string, err error)
+	Decode(body []byte, headers map[string]string) (*CloudEventsEnvelope, error)
+}
+
+var codecs = map[ContentMode]Codec{
+	ContentModeStructured: StructuredV1{},
+	ContentModeBinary:     BinaryV1{},
+}
+
+// CodecForContentMode returns the registered codec for mode, defaulting to the structured
+// content mode codec when mode is empty or unrecognized.
+func CodecForContentMode(mode ContentMode) Codec {
+	if codec, ok := codecs[mode]; ok {
+		return codec
+	}
+
+	return StructuredV1{}
+}
+
+// ContentModeFromMetadata reads the cloudEventContentMode metadata key a component declares
+// and returns the corresponding ContentMode, defaulting to structured mode.
+func ContentModeFromMetadata(metadata map[string]string) ContentMode {
+	if ContentMode(metadata[ContentModeMetadataKey]) == ContentModeBinary {
+		return ContentModeBinary
+	}
+
+	return ContentModeStructured
+}
+
+// StructuredV1 implements the CloudEvents v1.0 structured content mode: the envelope is
+// serialized as a single JSON document that becomes the message body.
+type StructuredV1 struct{}
+
+// Encode implements Codec.
+func (StructuredV1) Encode(env *CloudEventsEnvelope) ([]byte, map[string]string, error) {
+	body, err := json.Marshal(env)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return body, nil, nil
+}
+
+// Decode implements Codec.
+func (StructuredV1) Decode(body []byte, headers map[string]string) (*CloudEventsEnvelope, error) {
+	var env CloudEventsEnvelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return nil, err
+	}
+
+	return &env, nil
+}
+
+// BinaryV1 implements the CloudEvents v1.0 binary content mode: CloudEvents attributes are
+// carried as ce-* message metadata/headers and the message body is the raw event data.
+type BinaryV1 struct{}
+
+// Encode implements Codec.
+func (BinaryV1) Encode(env *CloudEventsEnvelope) ([]byte, map[string]string, error) {
+	headers := map[string]string{
+		ceHeaderID:          env.ID,
+		ceHeaderSource:      env.Source,
+		ceHeaderType:        env.Type,
+		ceHeaderSpecVersion: env.SpecVersion,
+	}
+	if env.Time != "" {
+		headers[ceHeaderTime] = env.Time
+	}
+	if env.Subject != "" {
+		headers[ceHeaderSubject] = env.Subject
+	}
+	if env.DataSchema != "" {
+		headers[ceHeaderDataSchema] = env.DataSchema
+	}
+	if env.DaprTraceID != "" {
+		headers[ceHeaderTraceID] = env.DaprTraceID
+	}
+	if env.Expiration != "" {
+		headers[ceHeaderExpiration] = env.Expiration
+	}
+	if env.DataContentType != "" {
+		headers[ceHeaderContentType] = env.DataContentType
+	}
+	for k, v := range env.Extensions {
+		headers[ceHeaderExtPrefix+k] = fmt.Sprintf("%v", v)
+	}
+
+	var body []byte
+	switch data := env.Data.(type) {
+	case nil:
+	case string:
+		body = []byte(data)
+	case []byte:
+		body = data
+	default:
+		b, err := json.Marshal(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		body = b
+	}
+
+	return body, headers, nil
+}
+
+// Decode implements Codec.
+func (BinaryV1) Decode(body []byte, headers map[string]string) (*CloudEventsEnvelope, error) {
+	env := &CloudEventsEnvelope{
+		ID:              headers[ceHeaderID],
+		Source:          headers[ceHeaderSource],
+		Type:            headers[ceHeaderType],
+		SpecVersion:     headers[ceHeaderSpecVersion],
+		Time:            headers[ceHeaderTime],
+		Subject:         headers[ceHeaderSubject],
+		DataSchema:      headers[ceHeaderDataSchema],
+		DaprTraceID:     headers[ceHeaderTraceID],
+		Expiration:      headers[ceHeaderExpiration],
+		DataContentType: headers[ceHeaderContentType],
+	}
+	if env.SpecVersion == "" {
+		env.SpecVersion = CloudEventsSpecVersion
+	}
+
+	for k, v := range headers {
+		if !strings.HasPrefix(k, ceHeaderExtPrefix) {
+			continue
+		}
+		key := strings.TrimPrefix(k, ceHeaderExtPrefix)
+		if ceKnownHeaders[key] {
+			continue
+		}
+		if env.Extensions == nil {
+			env.Extensions = map[string]interface{}{}
+		}
+		env.Extensions[key] = v
+	}
+
+	if len(body) > 0 {
+		env.Data = string(body)
+	}
+
+	return env, nil
+}
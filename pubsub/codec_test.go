@@ -0,0 +1,71 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodecForContentMode(t *testing.T) {
+	t.Run("structured by default", func(t *testing.T) {
+		assert.IsType(t, StructuredV1{}, CodecForContentMode(""))
+		assert.IsType(t, StructuredV1{}, CodecForContentMode("unknown"))
+	})
+
+	t.Run("binary when requested", func(t *testing.T) {
+		assert.IsType(t, BinaryV1{}, CodecForContentMode(ContentModeBinary))
+	})
+}
+
+func TestContentModeFromMetadata(t *testing.T) {
+	t.Run("defaults to structured", func(t *testing.T) {
+		assert.Equal(t, ContentModeStructured, ContentModeFromMetadata(map[string]string{}))
+	})
+
+	t.Run("binary opt-in", func(t *testing.T) {
+		md := map[string]string{ContentModeMetadataKey: "binary"}
+		assert.Equal(t, ContentModeBinary, ContentModeFromMetadata(md))
+	})
+}
+
+func TestStructuredV1RoundTrip(t *testing.T) {
+	env := NewCloudEventsEnvelope("a", "source", "e1", "", "mytopic", "mypubsub", "text/plain", []byte("data"), "1")
+
+	body, headers, err := StructuredV1{}.Encode(env)
+	assert.NoError(t, err)
+	assert.Nil(t, headers)
+
+	decoded, err := StructuredV1{}.Decode(body, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, env.ID, decoded.ID)
+	assert.Equal(t, env.Type, decoded.Type)
+	assert.Equal(t, env.DaprTraceID, decoded.DaprTraceID)
+}
+
+func TestBinaryV1RoundTrip(t *testing.T) {
+	env := NewCloudEventsEnvelope("a", "source", "e1", "", "mytopic", "mypubsub", "text/plain", []byte("data"), "1")
+	env.Expiration = "2030-01-01T00:00:00Z"
+
+	body, headers, err := BinaryV1{}.Encode(env)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", headers[ceHeaderID])
+	assert.Equal(t, "source", headers[ceHeaderSource])
+	assert.Equal(t, "e1", headers[ceHeaderType])
+	assert.Equal(t, "1", headers[ceHeaderTraceID])
+	assert.Equal(t, "2030-01-01T00:00:00Z", headers[ceHeaderExpiration])
+	assert.Equal(t, "data", string(body))
+
+	decoded, err := BinaryV1{}.Decode(body, headers)
+	assert.NoError(t, err)
+	assert.Equal(t, env.ID, decoded.ID)
+	assert.Equal(t, env.Source, decoded.Source)
+	assert.Equal(t, env.Type, decoded.Type)
+	assert.Equal(t, env.DaprTraceID, decoded.DaprTraceID)
+	assert.Equal(t, env.Expiration, decoded.Expiration)
+	assert.Equal(t, "data", decoded.Data)
+}
@@ -0,0 +1,97 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const deadLetterTopicMetadataKey = "deadLetterTopic"
+
+// Logger is the subset of dapr's structured logger DeliveryGuard needs to report dropped
+// and dead-lettered messages.
+type Logger interface {
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+}
+
+var expiredCounter metric.Int64Counter
+
+func init() {
+	var err error
+	expiredCounter, err = otel.Meter("github.com/dapr/components-contrib/pubsub").Int64Counter(
+		"dapr_pubsub_expired_total",
+		metric.WithDescription("Number of pub/sub messages dropped because they had already expired by delivery time."),
+	)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// DeliveryGuard wraps a pub/sub component's Subscribe handler so that no component needs
+// to remember to call CloudEventsEnvelope.HasExpired itself: expired messages are dropped
+// (or forwarded to a dead-letter topic, if configured) before the app handler ever runs.
+type DeliveryGuard struct {
+	// PubsubName is the component name recorded on the dapr_pubsub_expired_total metric.
+	PubsubName string
+	// DeadLetterTopic, when set, receives expired messages instead of silently dropping them.
+	DeadLetterTopic string
+	// Publish republishes a message to DeadLetterTopic. Required when DeadLetterTopic is set.
+	Publish func(req *PublishRequest) error
+
+	logger Logger
+}
+
+// NewDeliveryGuard builds a DeliveryGuard for pubsubName, reading the dead-letter topic (if
+// any) from the component's deadLetterTopic metadata.
+func NewDeliveryGuard(pubsubName string, metadata map[string]string, publish func(req *PublishRequest) error, logger Logger) *DeliveryGuard {
+	return &DeliveryGuard{
+		PubsubName:      pubsubName,
+		DeadLetterTopic: metadata[deadLetterTopicMetadataKey],
+		Publish:         publish,
+		logger:          logger,
+	}
+}
+
+// Wrap returns handler decorated with the expiration check. The component should subscribe
+// with the returned Handler in place of its own.
+func (g *DeliveryGuard) Wrap(handler Handler) Handler {
+	return func(msg *NewMessage) error {
+		env, err := CodecForContentMode(ContentModeFromMetadata(msg.Metadata)).Decode(msg.Data, msg.Metadata)
+		if err != nil {
+			// Not a CloudEvent we can reason about; let the app handler decide what to do with it.
+			return handler(msg)
+		}
+
+		if !env.HasExpired() {
+			return handler(msg)
+		}
+
+		expiredCounter.Add(context.Background(), 1, metric.WithAttributes(
+			attribute.String("pubsub", g.PubsubName),
+			attribute.String("topic", msg.Topic),
+		))
+
+		if g.logger != nil {
+			g.logger.Warnf("pubsub %s: dropping expired message %s on topic %s", g.PubsubName, env.ID, msg.Topic)
+		}
+
+		if g.DeadLetterTopic == "" || g.Publish == nil {
+			return nil
+		}
+
+		return g.Publish(&PublishRequest{
+			Data:       msg.Data,
+			PubsubName: g.PubsubName,
+			Topic:      g.DeadLetterTopic,
+			Metadata:   msg.Metadata,
+		})
+	}
+}
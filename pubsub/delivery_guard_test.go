@@ -0,0 +1,99 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type noopLogger struct{}
+
+func (noopLogger) Infof(format string, args ...interface{}) {}
+func (noopLogger) Warnf(format string, args ...interface{}) {}
+
+func expiredMessage() *NewMessage {
+	env := NewCloudEventsEnvelope("a", "source", "e1", "", "mytopic", "mypubsub", "text/plain", []byte("data"), "")
+	env.Expiration = time.Now().UTC().Add(-time.Hour).Format(time.RFC3339)
+
+	body, headers, _ := StructuredV1{}.Encode(env)
+
+	return &NewMessage{Data: body, Topic: "mytopic", Metadata: headers}
+}
+
+func expiredBinaryMessage() *NewMessage {
+	env := NewCloudEventsEnvelope("a", "source", "e1", "", "mytopic", "mypubsub", "text/plain", []byte("data"), "")
+	env.Expiration = time.Now().UTC().Add(-time.Hour).Format(time.RFC3339)
+
+	body, headers, _ := BinaryV1{}.Encode(env)
+	headers[ContentModeMetadataKey] = string(ContentModeBinary)
+
+	return &NewMessage{Data: body, Topic: "mytopic", Metadata: headers}
+}
+
+func TestDeliveryGuardWrap(t *testing.T) {
+	t.Run("expired message is dropped before reaching the handler", func(t *testing.T) {
+		called := false
+		handler := func(msg *NewMessage) error {
+			called = true
+			return nil
+		}
+
+		guard := NewDeliveryGuard("mypubsub", map[string]string{}, nil, noopLogger{})
+		err := guard.Wrap(handler)(expiredMessage())
+
+		assert.NoError(t, err)
+		assert.False(t, called)
+	})
+
+	t.Run("expired message is dead-lettered when configured", func(t *testing.T) {
+		var published *PublishRequest
+		publish := func(req *PublishRequest) error {
+			published = req
+			return nil
+		}
+
+		guard := NewDeliveryGuard("mypubsub", map[string]string{"deadLetterTopic": "dlq"}, publish, noopLogger{})
+		err := guard.Wrap(func(msg *NewMessage) error { return nil })(expiredMessage())
+
+		assert.NoError(t, err)
+		assert.NotNil(t, published)
+		assert.Equal(t, "dlq", published.Topic)
+	})
+
+	t.Run("expired binary-mode message is dropped before reaching the handler", func(t *testing.T) {
+		called := false
+		handler := func(msg *NewMessage) error {
+			called = true
+			return nil
+		}
+
+		guard := NewDeliveryGuard("mypubsub", map[string]string{}, nil, noopLogger{})
+		err := guard.Wrap(handler)(expiredBinaryMessage())
+
+		assert.NoError(t, err)
+		assert.False(t, called)
+	})
+
+	t.Run("non-expired message reaches the handler", func(t *testing.T) {
+		env := NewCloudEventsEnvelope("a", "source", "e1", "", "mytopic", "mypubsub", "text/plain", []byte("data"), "")
+		body, headers, _ := StructuredV1{}.Encode(env)
+
+		called := false
+		handler := func(msg *NewMessage) error {
+			called = true
+			return nil
+		}
+
+		guard := NewDeliveryGuard("mypubsub", map[string]string{}, nil, noopLogger{})
+		err := guard.Wrap(handler)(&NewMessage{Data: body, Topic: "mytopic", Metadata: headers})
+
+		assert.NoError(t, err)
+		assert.True(t, called)
+	})
+}
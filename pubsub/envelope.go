@@ -0,0 +1,407 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultCloudEventType is the default event type for an Dapr published event.
+	DefaultCloudEventType = "com.dapr.event.sent"
+	// CloudEventsSpecVersion is the CloudEvents spec version used by Dapr.
+	CloudEventsSpecVersion = "1.0"
+	// DefaultCloudEventSource is the default event source.
+	DefaultCloudEventSource = "Dapr"
+	// DefaultCloudEventDataContentType is the default content type for an Dapr published event.
+	DefaultCloudEventDataContentType = "text/plain"
+
+	// DaprTraceIDField is the CloudEvents extension attribute Dapr uses to carry the trace ID.
+	DaprTraceIDField = "traceid"
+
+	ttlInSecondsMetadataKey     = "ttlInSeconds"
+	brokerTTLSecondsMetadataKey = "brokerTTLSeconds"
+	cloudEventMetadataPrefix    = "cloudevent."
+)
+
+// knownEnvelopeFields are the top-level CloudEvents attributes CloudEventsEnvelope already
+// has dedicated struct fields for; anything else round-trips through Extensions instead.
+var knownEnvelopeFields = map[string]bool{
+	"id": true, "source": true, "type": true, "specversion": true,
+	"datacontenttype": true, "data": true, "time": true, "subject": true,
+	"dataschema": true, "topic": true, "pubsubname": true, "expiration": true,
+	"traceid": true,
+}
+
+// CloudEventsEnvelope describes the basic structure of a CloudEvents v1.0 event envelope
+// as produced and consumed by Dapr pub/sub.
+type CloudEventsEnvelope struct {
+	ID              string                 `json:"id"`
+	Source          string                 `json:"source"`
+	Type            string                 `json:"type"`
+	SpecVersion     string                 `json:"specversion"`
+	DataContentType string                 `json:"datacontenttype,omitempty"`
+	Data            interface{}            `json:"data,omitempty"`
+	Time            string                 `json:"time,omitempty"`
+	Subject         string                 `json:"subject,omitempty"`
+	DataSchema      string                 `json:"dataschema,omitempty"`
+	Topic           string                 `json:"topic"`
+	PubsubName      string                 `json:"pubsubname"`
+	Expiration      string                 `json:"expiration,omitempty"`
+	DaprTraceID     string                 `json:"traceid"`
+	Extensions      map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON flattens Extensions onto the envelope's top level, per the CloudEvents spec
+// treating extension attributes as first-class members of the event rather than a nested object.
+func (ce *CloudEventsEnvelope) MarshalJSON() ([]byte, error) {
+	type alias CloudEventsEnvelope
+
+	base, err := json.Marshal((*alias)(ce))
+	if err != nil {
+		return nil, err
+	}
+	if len(ce.Extensions) == 0 {
+		return base, nil
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(base, &m); err != nil {
+		return nil, err
+	}
+	for k, v := range ce.Extensions {
+		m[k] = v
+	}
+
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON restores Extensions from every top-level field the envelope has no
+// dedicated struct field for, so unknown attributes survive a round trip.
+func (ce *CloudEventsEnvelope) UnmarshalJSON(data []byte) error {
+	type alias CloudEventsEnvelope
+
+	var a alias
+	if err := json.Unmarshal(data, &a); err != nil {
+		return err
+	}
+	*ce = CloudEventsEnvelope(a)
+
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	for k, raw := range m {
+		if knownEnvelopeFields[k] {
+			continue
+		}
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			continue
+		}
+		if ce.Extensions == nil {
+			ce.Extensions = map[string]interface{}{}
+		}
+		ce.Extensions[k] = v
+	}
+
+	return nil
+}
+
+// NewCloudEventsEnvelope creates a CloudEvents v1.0 envelope from the given parameters.
+func NewCloudEventsEnvelope(id, source, eventType, eventTime, topic, pubsubName, dataContentType string, data []byte, traceID string) *CloudEventsEnvelope {
+	ce := &CloudEventsEnvelope{
+		ID:              id,
+		Type:            eventType,
+		Source:          source,
+		Time:            eventTime,
+		DataContentType: dataContentType,
+		Topic:           topic,
+		PubsubName:      pubsubName,
+		DaprTraceID:     traceID,
+	}
+
+	applyCloudEventDefaults(ce, data)
+
+	return ce
+}
+
+// applyCloudEventDefaults fills in the required CloudEvents attributes that were left
+// empty, derives DataContentType from the raw payload when no content type was given, and
+// sets Data from the payload according to DataContentType.
+func applyCloudEventDefaults(ce *CloudEventsEnvelope, data []byte) {
+	if ce.ID == "" {
+		ce.ID = uuid.New().String()
+	}
+	if ce.Source == "" {
+		ce.Source = DefaultCloudEventSource
+	}
+	if ce.Type == "" {
+		ce.Type = DefaultCloudEventType
+	}
+	ce.SpecVersion = CloudEventsSpecVersion
+
+	if ce.DataContentType == "" {
+		if isJSON(data) {
+			ce.DataContentType = "application/json"
+		} else {
+			ce.DataContentType = DefaultCloudEventDataContentType
+		}
+	}
+
+	if len(data) > 0 {
+		switch ce.DataContentType {
+		case "application/json":
+			var v interface{}
+			if err := json.Unmarshal(data, &v); err == nil {
+				ce.Data = v
+			}
+		default:
+			ce.Data = string(data)
+		}
+	}
+}
+
+// Option configures a CloudEventsEnvelope built via NewCloudEventsEnvelopeWithOptions.
+type Option func(ce *CloudEventsEnvelope, data *[]byte)
+
+// NewCloudEventsEnvelopeWithOptions builds a CloudEventsEnvelope from a set of Options,
+// as a less unwieldy alternative to NewCloudEventsEnvelope's growing positional-arg signature.
+func NewCloudEventsEnvelopeWithOptions(opts ...Option) *CloudEventsEnvelope {
+	ce := &CloudEventsEnvelope{}
+
+	var data []byte
+	for _, opt := range opts {
+		opt(ce, &data)
+	}
+
+	applyCloudEventDefaults(ce, data)
+
+	return ce
+}
+
+// WithID sets the event ID.
+func WithID(id string) Option {
+	return func(ce *CloudEventsEnvelope, _ *[]byte) { ce.ID = id }
+}
+
+// WithSource sets the event source.
+func WithSource(source string) Option {
+	return func(ce *CloudEventsEnvelope, _ *[]byte) { ce.Source = source }
+}
+
+// WithType sets the event type.
+func WithType(eventType string) Option {
+	return func(ce *CloudEventsEnvelope, _ *[]byte) { ce.Type = eventType }
+}
+
+// WithTime sets the event time.
+func WithTime(eventTime string) Option {
+	return func(ce *CloudEventsEnvelope, _ *[]byte) { ce.Time = eventTime }
+}
+
+// WithTopic sets the Dapr topic the event was published to.
+func WithTopic(topic string) Option {
+	return func(ce *CloudEventsEnvelope, _ *[]byte) { ce.Topic = topic }
+}
+
+// WithPubsubName sets the name of the pub/sub component the event was published through.
+func WithPubsubName(pubsubName string) Option {
+	return func(ce *CloudEventsEnvelope, _ *[]byte) { ce.PubsubName = pubsubName }
+}
+
+// WithDataContentType sets the content type of the event data.
+func WithDataContentType(dataContentType string) Option {
+	return func(ce *CloudEventsEnvelope, _ *[]byte) { ce.DataContentType = dataContentType }
+}
+
+// WithData sets the raw event payload, to be interpreted according to DataContentType.
+func WithData(data []byte) Option {
+	return func(_ *CloudEventsEnvelope, d *[]byte) { *d = data }
+}
+
+// WithTraceID sets the Dapr trace ID carried on the event.
+func WithTraceID(traceID string) Option {
+	return func(ce *CloudEventsEnvelope, _ *[]byte) { ce.DaprTraceID = traceID }
+}
+
+// WithSubject sets the CloudEvents subject attribute.
+func WithSubject(subject string) Option {
+	return func(ce *CloudEventsEnvelope, _ *[]byte) { ce.Subject = subject }
+}
+
+// WithDataSchema sets the CloudEvents dataschema attribute.
+func WithDataSchema(dataSchema string) Option {
+	return func(ce *CloudEventsEnvelope, _ *[]byte) { ce.DataSchema = dataSchema }
+}
+
+// WithExtension sets a single CloudEvents extension attribute.
+func WithExtension(key string, value interface{}) Option {
+	return func(ce *CloudEventsEnvelope, _ *[]byte) {
+		if ce.Extensions == nil {
+			ce.Extensions = map[string]interface{}{}
+		}
+		ce.Extensions[key] = value
+	}
+}
+
+// WithExtensions sets every CloudEvents extension attribute in extensions.
+func WithExtensions(extensions map[string]interface{}) Option {
+	return func(ce *CloudEventsEnvelope, _ *[]byte) {
+		if ce.Extensions == nil {
+			ce.Extensions = map[string]interface{}{}
+		}
+		for k, v := range extensions {
+			ce.Extensions[k] = v
+		}
+	}
+}
+
+func isJSON(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	var js json.RawMessage
+	return json.Unmarshal(data, &js) == nil
+}
+
+// HasExpired returns true if the envelope carries an Expiration timestamp that is in the past.
+func (ce *CloudEventsEnvelope) HasExpired() bool {
+	if ce.Expiration == "" {
+		return false
+	}
+	expiration, err := time.Parse(time.RFC3339, ce.Expiration)
+	if err != nil {
+		return false
+	}
+	return expiration.UTC().Before(time.Now().UTC())
+}
+
+// ApplyMetadata sets envelope properties derived from component metadata, such as a
+// message TTL. When the component natively supports message TTL, the TTL is only applied
+// if the brokerTTLSeconds metadata reports a native TTL shorter than the requested one.
+func (ce *CloudEventsEnvelope) ApplyMetadata(features []Feature, metadata map[string]string) {
+	for k, v := range metadata {
+		key := strings.TrimPrefix(k, cloudEventMetadataPrefix)
+		if key == k || key == "" {
+			continue
+		}
+		if ce.Extensions == nil {
+			ce.Extensions = map[string]interface{}{}
+		}
+		ce.Extensions[key] = v
+	}
+
+	ttlValue, ok := metadata[ttlInSecondsMetadataKey]
+	if !ok || ttlValue == "" {
+		return
+	}
+
+	ttlInSeconds, err := strconv.ParseInt(ttlValue, 10, 64)
+	if err != nil {
+		return
+	}
+
+	if FeatureMessageTTL.IsPresent(features) {
+		brokerTTLValue, ok := metadata[brokerTTLSecondsMetadataKey]
+		if !ok {
+			// No evidence the broker's native TTL falls short of what was requested; trust it.
+			return
+		}
+
+		brokerTTLSeconds, err := strconv.ParseInt(brokerTTLValue, 10, 64)
+		if err != nil || brokerTTLSeconds >= ttlInSeconds {
+			return
+		}
+		// The broker's native TTL is shorter than requested: the envelope still carries the
+		// requested Expiration, as defense-in-depth, so consumers can check it with HasExpired.
+	}
+
+	now := time.Now().UTC()
+	maxSeconds := int64((math.MaxInt64 - now.UnixNano()) / int64(time.Second))
+	if ttlInSeconds > maxSeconds {
+		ttlInSeconds = maxSeconds
+	}
+
+	ce.Expiration = now.Add(time.Duration(ttlInSeconds) * time.Second).Format(time.RFC3339)
+}
+
+// SetTraceID sets the Dapr trace ID extension attribute on a raw CloudEvents JSON payload,
+// preserving every other field already present in it. jsonPayload may be a single event
+// object or a CloudEvents JSON Batch array, in which case every event in it is updated.
+func SetTraceID(jsonPayload []byte, traceID string) ([]byte, error) {
+	if isBatch(jsonPayload) {
+		return setBatchTraceID(jsonPayload, traceID)
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(jsonPayload, &m); err != nil {
+		return nil, err
+	}
+
+	m[DaprTraceIDField] = traceID
+
+	return json.Marshal(m)
+}
+
+func setBatchTraceID(jsonPayload []byte, traceID string) ([]byte, error) {
+	var events []map[string]interface{}
+	if err := json.Unmarshal(jsonPayload, &events); err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		event[DaprTraceIDField] = traceID
+	}
+
+	return json.Marshal(events)
+}
+
+func isBatch(jsonPayload []byte) bool {
+	trimmed := bytes.TrimSpace(jsonPayload)
+
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// DataContentTypeBatch is the DataContentType of a CloudEvents JSON Batch Format payload,
+// per https://github.com/cloudevents/spec/blob/v1.0/json-format.md#4-json-batch-format.
+const DataContentTypeBatch = "application/cloudevents-batch+json"
+
+// CloudEventsBatch is a sequence of CloudEvents serialized, as a bare JSON array, per the
+// CloudEvents JSON Batch Format. Components that declare FeatureBulkPublish can transmit one
+// of these as a single message instead of fanning events out individually.
+type CloudEventsBatch []CloudEventsEnvelope
+
+// NewCloudEventsBatchEnvelope assembles a CloudEventsBatch from events.
+func NewCloudEventsBatchEnvelope(events []CloudEventsEnvelope) CloudEventsBatch {
+	return CloudEventsBatch(events)
+}
+
+// UnmarshalBatch parses a CloudEvents JSON Batch Format payload into its individual events.
+func UnmarshalBatch(data []byte) ([]*CloudEventsEnvelope, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	events := make([]*CloudEventsEnvelope, 0, len(raw))
+	for _, r := range raw {
+		var env CloudEventsEnvelope
+		if err := json.Unmarshal(r, &env); err != nil {
+			return nil, err
+		}
+		events = append(events, &env)
+	}
+
+	return events, nil
+}
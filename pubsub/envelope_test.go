@@ -148,6 +148,16 @@ func TestCreateCloudEventsEnvelopeExpiration(t *testing.T) {
 		assert.False(t, envelope.HasExpired())
 	})
 
+	t.Run("cloud event TTL from metadata applies as defense-in-depth when broker TTL is shorter", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "", "", "routed.topic", "mypubsub", "", []byte(str), "")
+		envelope.ApplyMetadata([]Feature{FeatureMessageTTL}, map[string]string{
+			"ttlInSeconds":     "10000",
+			"brokerTTLSeconds": "10",
+		})
+		assert.NotEqual(t, "", envelope.Expiration)
+		assert.False(t, envelope.HasExpired())
+	})
+
 	t.Run("cloud event with max TTL metadata", func(t *testing.T) {
 		envelope := NewCloudEventsEnvelope("a", "", "", "", "routed.topic", "mypubsub", "", []byte(str), "")
 		envelope.ApplyMetadata(nil, map[string]string{
@@ -175,6 +185,68 @@ func TestCreateCloudEventsEnvelopeExpiration(t *testing.T) {
 	})
 }
 
+func TestCloudEventsEnvelopeExtensions(t *testing.T) {
+	t.Run("extensions round-trip through JSON", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelopeWithOptions(
+			WithID("a"),
+			WithSource("source"),
+			WithSubject("123"),
+			WithDataSchema("https://example.com/schema"),
+			WithExtension("comexampleextension1", "value"),
+			WithData([]byte("data")),
+		)
+		assert.Equal(t, "123", envelope.Subject)
+		assert.Equal(t, "https://example.com/schema", envelope.DataSchema)
+
+		b, err := json.Marshal(envelope)
+		assert.NoError(t, err)
+
+		var m map[string]interface{}
+		assert.NoError(t, json.Unmarshal(b, &m))
+		assert.Equal(t, "value", m["comexampleextension1"])
+		assert.Equal(t, "123", m["subject"])
+
+		var decoded CloudEventsEnvelope
+		assert.NoError(t, json.Unmarshal(b, &decoded))
+		assert.Equal(t, "value", decoded.Extensions["comexampleextension1"])
+	})
+
+	t.Run("ApplyMetadata promotes cloudevent. prefixed keys", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "source", "", "", "", "mypubsub", "", nil, "")
+		envelope.ApplyMetadata(nil, map[string]string{
+			"cloudevent.partitionkey": "123",
+			"unrelated":               "ignored",
+		})
+		assert.Equal(t, "123", envelope.Extensions["partitionkey"])
+		_, ok := envelope.Extensions["unrelated"]
+		assert.False(t, ok)
+	})
+}
+
+func TestCreateCloudEventsBatchEnvelope(t *testing.T) {
+	t.Run("mixed content types", func(t *testing.T) {
+		events := []CloudEventsEnvelope{
+			*NewCloudEventsEnvelope("a", "source", "e1", "", "mytopic", "mypubsub", "application/json", []byte(`{"k":"v"}`), ""),
+			*NewCloudEventsEnvelope("b", "source", "e2", "", "mytopic", "mypubsub", "text/plain", []byte("data"), ""),
+		}
+
+		batch := NewCloudEventsBatchEnvelope(events)
+		assert.Len(t, batch, 2)
+
+		b, err := json.Marshal(batch)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(strings.TrimSpace(string(b)), "["))
+
+		decoded, err := UnmarshalBatch(b)
+		assert.NoError(t, err)
+		assert.Len(t, decoded, 2)
+		assert.Equal(t, "a", decoded[0].ID)
+		assert.Equal(t, "application/json", decoded[0].DataContentType)
+		assert.Equal(t, "b", decoded[1].ID)
+		assert.Equal(t, "text/plain", decoded[1].DataContentType)
+	})
+}
+
 func TestSetTraceID(t *testing.T) {
 	t.Run("invalid json", func(t *testing.T) {
 		_, err := SetTraceID([]byte("a"), "1")
@@ -196,4 +268,23 @@ func TestSetTraceID(t *testing.T) {
 		assert.Equal(t, "1.0", m["specversion"])
 		assert.Equal(t, "1", m[DaprTraceIDField])
 	})
+
+	t.Run("valid batch json", func(t *testing.T) {
+		batch := []map[string]interface{}{
+			{"specversion": "1.0", "customfield": "a"},
+			{"specversion": "1.0", "customfield": "b"},
+		}
+
+		b, err := json.Marshal(batch)
+		assert.NoError(t, err)
+		ce, err := SetTraceID(b, "1")
+		assert.NoError(t, err)
+
+		var decoded []map[string]interface{}
+		assert.NoError(t, json.Unmarshal(ce, &decoded))
+		assert.Len(t, decoded, 2)
+		assert.Equal(t, "1", decoded[0][DaprTraceIDField])
+		assert.Equal(t, "1", decoded[1][DaprTraceIDField])
+		assert.Equal(t, "a", decoded[0]["customfield"])
+	})
 }
@@ -0,0 +1,68 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+// PubSub is the interface for message buses implementing the Dapr pub/sub building block.
+type PubSub interface {
+	Init(metadata Metadata) error
+	Features() []Feature
+	Publish(req *PublishRequest) error
+	Subscribe(req SubscribeRequest, handler Handler) error
+}
+
+// Metadata carries component configuration as provided in the Dapr component spec.
+type Metadata struct {
+	Properties map[string]string
+}
+
+// PublishRequest is the request object to perform a publish operation on a topic.
+type PublishRequest struct {
+	Data       []byte
+	PubsubName string
+	Topic      string
+	Metadata   map[string]string
+}
+
+// SubscribeRequest is the request object to perform a subscribe operation on a topic.
+type SubscribeRequest struct {
+	Topic    string
+	Metadata map[string]string
+}
+
+// NewMessage is an event arriving from a message bus instance.
+type NewMessage struct {
+	Data     []byte
+	Topic    string
+	Metadata map[string]string
+}
+
+// Handler is the handler used to invoke the app handler for a given subscribed topic.
+type Handler func(msg *NewMessage) error
+
+// Feature names a feature that can be implemented by PubSub components.
+type Feature string
+
+const (
+	// FeatureMessageTTL is the feature that a component supports message-level TTL natively,
+	// so Dapr does not set its own envelope Expiration unless the broker's reported native
+	// TTL (brokerTTLSeconds metadata) is shorter than the one requested.
+	FeatureMessageTTL Feature = "MESSAGE_TTL"
+	// FeatureBulkPublish is the feature that a component can natively transmit a
+	// CloudEvents JSON Batch Format message; without it, the runtime must fan events out
+	// to the broker one at a time instead.
+	FeatureBulkPublish Feature = "BULK_PUBLISH"
+)
+
+// IsPresent checks if a given feature is present in the list.
+func (f Feature) IsPresent(features []Feature) bool {
+	for _, feature := range features {
+		if feature == f {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,236 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+// Package query lets pub/sub subscribers declare a filter expression evaluated against
+// CloudEvents attributes, so components without server-side filtering (Redis Streams,
+// in-memory, ...) can still drop non-matching events before they reach the app handler.
+//
+// The expression language supports equality, LIKE prefix matching, EXISTS, and numeric/time
+// comparisons, ANDed together, e.g.:
+//
+//	type = 'com.github.pull.create' AND source LIKE 'https://github.com/%' AND ce.priority > 3
+//
+// Standard CloudEvents attributes (id, type, source, subject, datacontenttype, time) are
+// referenced by name; extension attributes are referenced as ce.<name>.
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dapr/components-contrib/pubsub"
+)
+
+// Query matches a CloudEventsEnvelope against a filter expression.
+type Query interface {
+	Matches(env *pubsub.CloudEventsEnvelope) (bool, error)
+}
+
+// Empty is a Query that matches every event, used when a subscriber declares no filter.
+type Empty struct{}
+
+// Matches implements Query.
+func (Empty) Matches(*pubsub.CloudEventsEnvelope) (bool, error) {
+	return true, nil
+}
+
+type operator string
+
+const (
+	opEq     operator = "="
+	opLike   operator = "LIKE"
+	opExists operator = "EXISTS"
+	opGt     operator = ">"
+	opGte    operator = ">="
+	opLt     operator = "<"
+	opLte    operator = "<="
+)
+
+// knownOperators are the operator tokens parseCondition accepts, used both to validate the
+// second token of a condition and to reject a leftover operator masquerading as a value.
+var knownOperators = map[operator]bool{
+	opEq: true, opLike: true, opExists: true, opGt: true, opGte: true, opLt: true, opLte: true,
+}
+
+type condition struct {
+	field string
+	op    operator
+	value string
+}
+
+type parsedQuery struct {
+	conditions []condition
+}
+
+// Matches implements Query.
+func (q *parsedQuery) Matches(env *pubsub.CloudEventsEnvelope) (bool, error) {
+	for _, c := range q.conditions {
+		ok, err := c.matches(env)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// Parse compiles a filter expression into a Query. An empty expression returns Empty{}.
+func Parse(expr string) (Query, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return Empty{}, nil
+	}
+
+	parts := strings.Split(expr, " AND ")
+	conditions := make([]condition, 0, len(parts))
+	for _, part := range parts {
+		c, err := parseCondition(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, c)
+	}
+
+	return &parsedQuery{conditions: conditions}, nil
+}
+
+// parseCondition tokenizes `<field> <op> <value>` on whitespace, rather than matching it
+// with a single greedy regex: a regex whose value group is `(.*)` happily accepts a bare
+// leftover operator (e.g. "type ==") as if it were a quoted value instead of rejecting it.
+func parseCondition(part string) (condition, error) {
+	tokens := strings.Fields(part)
+	if len(tokens) < 2 {
+		return condition{}, fmt.Errorf("query: invalid condition %q", part)
+	}
+
+	field, op := tokens[0], operator(tokens[1])
+	if !knownOperators[op] {
+		return condition{}, fmt.Errorf("query: invalid condition %q", part)
+	}
+
+	if op == opExists {
+		if len(tokens) != 2 {
+			return condition{}, fmt.Errorf("query: invalid condition %q", part)
+		}
+
+		return condition{field: field, op: op}, nil
+	}
+
+	if len(tokens) < 3 {
+		return condition{}, fmt.Errorf("query: condition %q is missing a value", part)
+	}
+
+	value := unquote(strings.Join(tokens[2:], " "))
+	if knownOperators[operator(value)] {
+		return condition{}, fmt.Errorf("query: invalid condition %q", part)
+	}
+
+	return condition{field: field, op: op, value: value}, nil
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return value[1 : len(value)-1]
+	}
+
+	return value
+}
+
+func (c condition) matches(env *pubsub.CloudEventsEnvelope) (bool, error) {
+	value, ok := attribute(env, c.field)
+	if c.op == opExists {
+		return ok, nil
+	}
+	if !ok {
+		return false, nil
+	}
+
+	switch c.op {
+	case opEq:
+		return fmt.Sprintf("%v", value) == c.value, nil
+	case opLike:
+		return matchesLike(fmt.Sprintf("%v", value), c.value), nil
+	case opGt, opGte, opLt, opLte:
+		left, lok := comparable(value)
+		right, rok := comparable(c.value)
+		if !lok || !rok {
+			return false, fmt.Errorf("query: cannot compare %q", c.field)
+		}
+
+		switch c.op {
+		case opGt:
+			return left > right, nil
+		case opGte:
+			return left >= right, nil
+		case opLt:
+			return left < right, nil
+		default:
+			return left <= right, nil
+		}
+	default:
+		return false, fmt.Errorf("query: unsupported operator %q", c.op)
+	}
+}
+
+func matchesLike(value, pattern string) bool {
+	if strings.HasSuffix(pattern, "%") {
+		return strings.HasPrefix(value, strings.TrimSuffix(pattern, "%"))
+	}
+
+	return value == pattern
+}
+
+// attribute resolves a standard CloudEvents attribute or, for fields prefixed with "ce.",
+// an extension attribute.
+func attribute(env *pubsub.CloudEventsEnvelope, field string) (interface{}, bool) {
+	switch field {
+	case "id":
+		return env.ID, env.ID != ""
+	case "type":
+		return env.Type, env.Type != ""
+	case "source":
+		return env.Source, env.Source != ""
+	case "subject":
+		return env.Subject, env.Subject != ""
+	case "datacontenttype":
+		return env.DataContentType, env.DataContentType != ""
+	case "time":
+		return env.Time, env.Time != ""
+	default:
+		if !strings.HasPrefix(field, "ce.") {
+			return nil, false
+		}
+		v, ok := env.Extensions[strings.TrimPrefix(field, "ce.")]
+
+		return v, ok
+	}
+}
+
+// comparable coerces a value to a float64 so numeric and RFC3339 time comparisons can share
+// the same operators.
+func comparable(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case int:
+		return float64(val), true
+	case string:
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			return f, true
+		}
+		if t, err := time.Parse(time.RFC3339, val); err == nil {
+			return float64(t.Unix()), true
+		}
+
+		return 0, false
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,116 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dapr/components-contrib/pubsub"
+)
+
+func newEnvelope() *pubsub.CloudEventsEnvelope {
+	env := pubsub.NewCloudEventsEnvelope("a", "https://github.com/cloudevents/spec/pull", "com.github.pull.create", "", "", "mypubsub", "", nil, "")
+	env.Subject = "123"
+	env.Extensions = map[string]interface{}{"priority": 5}
+
+	return env
+}
+
+func TestEmptyMatchesEverything(t *testing.T) {
+	ok, err := Empty{}.Matches(newEnvelope())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestParseAndMatch(t *testing.T) {
+	t.Run("equality", func(t *testing.T) {
+		q, err := Parse("type = 'com.github.pull.create'")
+		assert.NoError(t, err)
+		ok, err := q.Matches(newEnvelope())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("prefix LIKE", func(t *testing.T) {
+		q, err := Parse("source LIKE 'https://github.com/%'")
+		assert.NoError(t, err)
+		ok, err := q.Matches(newEnvelope())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("exists", func(t *testing.T) {
+		q, err := Parse("ce.priority EXISTS")
+		assert.NoError(t, err)
+		ok, err := q.Matches(newEnvelope())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		q, err = Parse("ce.missing EXISTS")
+		assert.NoError(t, err)
+		ok, err = q.Matches(newEnvelope())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("numeric comparison on extension", func(t *testing.T) {
+		q, err := Parse("ce.priority > 3")
+		assert.NoError(t, err)
+		ok, err := q.Matches(newEnvelope())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("combined AND conditions", func(t *testing.T) {
+		q, err := Parse("type = 'com.github.pull.create' AND source LIKE 'https://github.com/%' AND ce.priority > 3")
+		assert.NoError(t, err)
+		ok, err := q.Matches(newEnvelope())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("no match drops the event", func(t *testing.T) {
+		q, err := Parse("type = 'com.other.event'")
+		assert.NoError(t, err)
+		ok, err := q.Matches(newEnvelope())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("invalid expression", func(t *testing.T) {
+		_, err := Parse("type ==")
+		assert.Error(t, err)
+	})
+}
+
+func TestSubscriptionFiltersChannel(t *testing.T) {
+	in := make(chan *pubsub.CloudEventsEnvelope, 2)
+	q, err := Parse("type = 'com.github.pull.create'")
+	assert.NoError(t, err)
+
+	sub := NewSubscription(in, q, 2)
+
+	matching := newEnvelope()
+	nonMatching := newEnvelope()
+	nonMatching.Type = "com.other.event"
+
+	in <- nonMatching
+	in <- matching
+	close(in)
+
+	select {
+	case env := <-sub.Out:
+		assert.Equal(t, matching.ID, env.ID)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for matching event")
+	}
+
+	_, ok := <-sub.Out
+	assert.False(t, ok)
+}
@@ -0,0 +1,68 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package query
+
+import (
+	"context"
+
+	"github.com/dapr/components-contrib/pubsub"
+)
+
+// Subscription filters an incoming channel of CloudEvents against a Query, forwarding only
+// the events that match onto Out. It is intended for brokers that have no server-side
+// filtering of their own, such as Redis Streams or the in-memory pub/sub.
+type Subscription struct {
+	Out chan *pubsub.CloudEventsEnvelope
+
+	query  Query
+	cancel context.CancelFunc
+}
+
+// NewSubscription starts filtering in against q, delivering matches on the returned
+// Subscription's Out channel until in is closed or Cancel is called.
+func NewSubscription(in <-chan *pubsub.CloudEventsEnvelope, q Query, bufSize int) *Subscription {
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := &Subscription{
+		Out:    make(chan *pubsub.CloudEventsEnvelope, bufSize),
+		query:  q,
+		cancel: cancel,
+	}
+
+	go sub.run(ctx, in)
+
+	return sub
+}
+
+func (s *Subscription) run(ctx context.Context, in <-chan *pubsub.CloudEventsEnvelope) {
+	defer close(s.Out)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case env, ok := <-in:
+			if !ok {
+				return
+			}
+
+			matched, err := s.query.Matches(env)
+			if err != nil || !matched {
+				continue
+			}
+
+			select {
+			case s.Out <- env:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// Cancel stops the subscription's filtering goroutine and closes Out.
+func (s *Subscription) Cancel() {
+	s.cancel()
+}